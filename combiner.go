@@ -18,191 +18,177 @@ package main
 
 import (
 	"bufio"
-	"cloud.google.com/go/storage"
+	"container/heap"
 	"context"
+	"flag"
 	"fmt"
-	"google.golang.org/api/iterator"
-	"google.golang.org/api/option"
-	"io"
 	"log"
 	"os"
-	"path/filepath"
-	"regexp"
-	"sort"
+	"os/signal"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
-func main() {
-	ctx := context.Background()
+// logLine is a single line read from a source, tagged with everything
+// needed to order it against lines from every other source and to render
+// it in the combined output.
+type logLine struct {
+	time        time.Time
+	sourceIndex int
+	rowNumber   int
+	shortName   string
+	text        string
+}
+
+// sourceMessage is a response to a single "advance" request for a source:
+// either its next line, a terminal error, or eof once it's exhausted.
+type sourceMessage struct {
+	line logLine
+	err  error
+	eof  bool
+}
 
-	if len(os.Args) != 2 {
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	timezone := flag.String("timezone", "UTC", "Timezone to assume for lines that carry no zone information.")
+	sourceTimezones := sourceTimezoneFlag{}
+	flag.Var(&sourceTimezones, "source-timezone", "Per-source timezone override in name=zone form (e.g. kube-apiserver=America/Los_Angeles). May be repeated.")
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "Maximum number of sources to read concurrently. Values less than 1 are treated as 1.")
+	maxLineBytes := flag.Int("max-line-bytes", 32*1024*1024, "Maximum size, in bytes, of a single log line. Raise this if you see a 'token too long' error.")
+	includeSource := flag.String("include-source", "", "Only combine sources whose short name matches this glob pattern.")
+	excludeSource := flag.String("exclude-source", "", "Exclude sources whose short name matches this glob pattern.")
+	component := flag.String("component", "", "Only combine sources matching a Kubernetes component selector, e.g. pod=my-pod.")
+	grep := flag.String("grep", "", "Only combine lines whose body matches this regular expression.")
+	grepV := flag.String("grep-v", "", "Exclude lines whose body matches this regular expression.")
+	since := flag.String("since", "", "Drop lines timestamped before this RFC3339 time.")
+	until := flag.String("until", "", "Drop lines timestamped after this RFC3339 time.")
+	output := flag.String("output", "", "Output format: text, json, or color (default: color on a TTY, text otherwise).")
+	noColor := flag.Bool("no-color", false, "Disable color output even when attached to a TTY.")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
 		log.Fatalf("missing path argument")
 	}
-	path := os.Args[1]
+	path := flag.Arg(0)
 
-	var objectNames []string
-	var prefix string
-	var getReader func(ctx context.Context, name string) (io.ReadCloser, error)
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
 
-	var urlPattern = regexp.MustCompile(`https?://`)
-	if urlPattern.MatchString(path) {
-		// Bucket source
-		client, err := storage.NewClient(ctx, option.WithoutAuthentication())
-		if err != nil {
-			log.Fatalf("failed to create storage client: %v", err)
-		}
-		defer client.Close()
-		bucketName := "kubernetes-jenkins"
-		if strings.Contains(path, bucketName) {
-			prefix = strings.Split(path, "/"+bucketName+"/")[1]
-		} else {
-			log.Fatalf("unable to determine prefix from the specified path")
-		}
-		bucket := client.Bucket(bucketName)
-		q := &storage.Query{Prefix: prefix}
-		if err := q.SetAttrSelection([]string{"Name"}); err != nil {
-			log.Fatalf("failed to set attr selection: %v", err)
-		}
-		objects := bucket.Objects(ctx, q)
-		for {
-			objAttrs, err := objects.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				log.Fatalf("iterator error: %v", err)
-			}
-			if strings.HasSuffix(objAttrs.Name, ".log") || strings.HasSuffix(objAttrs.Name, "build-log.txt") {
-				objectNames = append(objectNames, objAttrs.Name)
-			}
-		}
-		getReader = func(ctx context.Context, name string) (io.ReadCloser, error) {
-			return bucket.Object(name).NewReader(ctx)
-		}
-	} else {
-		// Local file source
-		var err error
-		prefix, err = filepath.Abs(path)
-		if err != nil {
-			log.Fatalf("failed to get object absolute path from %v : %v", path, err)
-		}
-		err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() && (strings.HasSuffix(path, ".log") || strings.HasSuffix(path, "build-log.txt")) {
-				objectNames = append(objectNames, path)
-			}
-			return nil
-		})
-		if err != nil {
-			log.Fatalf("failed to get object names from path %v : %v", path, err)
-		}
-		getReader = func(ctx context.Context, name string) (io.ReadCloser, error) {
-			return os.Open(name)
-		}
+	defaultLoc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatalf("invalid --timezone %q: %v", *timezone, err)
+	}
+	sourceLocs, err := sourceTimezones.locations()
+	if err != nil {
+		log.Fatalf("invalid --source-timezone: %v", err)
 	}
 
-	resultChan := make(chan []string, 16)
-	errorChan := make(chan error)
+	lf, err := newLineFilter(*grep, *grepV, *since, *until)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sf := sourceFilter{includeGlob: *includeSource, excludeGlob: *excludeSource, component: *component}
 
-	for i, name := range objectNames {
-		go func(i int, name string) {
-			reader, err := getReader(ctx, name)
-			if err != nil {
-				errorChan <- fmt.Errorf("failed to create new reader for %v: %v", name, err)
-			}
-			defer reader.Close()
-			scanner := bufio.NewScanner(reader)
-			maxTokenSize := 32 * 1024 * 1024
-			buf := make([]byte, 0, maxTokenSize)
-			scanner.Buffer(buf, maxTokenSize)
-			scanner.Split(bufio.ScanLines)
-
-			nameWithoutPrefix := strings.TrimPrefix(name, prefix)
-			shortName := shortName(nameWithoutPrefix)
-
-			var lineTime time.Time
-			emptyTime := time.Time{}
-			firstTime := emptyTime
-			dayNumber := 0
-			var rowNumber = 0
-			var lines []string
-			for scanner.Scan() {
-				rowNumber++
-				line := scanner.Text()
-				lineTime, err = parseLineTime(line, lineTime)
-				if err != nil {
-					errorChan <- fmt.Errorf("unable to parse line time: %v", err)
-				}
-				if firstTime == emptyTime {
-					firstTime = lineTime
-				}
-				if lineTime.Hour() < firstTime.Hour()-1 {
-					dayNumber = 1
-				}
-
-				sortKey := fmt.Sprintf("%d:%02d:%02d:%02d.%09d:%04d:%08d", dayNumber, lineTime.Hour(), lineTime.Minute(), lineTime.Second(), lineTime.Nanosecond(), i, rowNumber)
-				displayTime := fmt.Sprintf("%02d:%02d:%02d.%09d", lineTime.Hour(), lineTime.Minute(), lineTime.Second(), lineTime.Nanosecond())
-				lines = append(lines, fmt.Sprintf("%s %s %-62s %s", sortKey, displayTime, "["+shortName+"]", line))
-			}
-			if scanner.Err() != nil {
-				errorChan <- scanner.Err()
-			}
-
-			resultChan <- lines
-		}(i, name)
-	}
-
-	var combinedLines []string
-	for i := 0; i < len(objectNames); i++ {
-		select {
-		case lines := <-resultChan:
-			combinedLines = append(combinedLines, lines...)
-		case err := <-errorChan:
-			log.Fatal(err)
-		}
+	formatter, err := newLineFormatter(*output, *noColor)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	sort.Strings(combinedLines)
+	src, err := newSource(ctx, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	allObjectNames, prefix, err := src.list(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	bw := bufio.NewWriter(os.Stdout)
-	defer bw.Flush()
-	for _, line := range combinedLines {
-		// Write line to output without sort key (first 35 chars)
-		if _, err := bw.WriteString(line[35:] + "\n"); err != nil {
-			log.Fatalf("failed to write string: %v", err)
+	var objectNames []string
+	for _, name := range allObjectNames {
+		short := shortName(strings.TrimPrefix(name, prefix))
+		ok, err := sf.matches(short, name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if ok {
+			objectNames = append(objectNames, name)
 		}
 	}
-}
 
-var timeNanoPattern = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}\.\d{9})`)  // Example: 22:10:34.002031939
-var timeMicroPattern = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}\.\d{6})`) // Example: 22:10:34.002031
-var timeMilliPattern = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}\.\d{3})`) // Example: 22:10:34.002
-var timePattern = regexp.MustCompile(`(\d{2}:\d{2}:\d{2})`)            // Example: 22:10:34
+	// Scanner buffers are expensive (up to --max-line-bytes each). A
+	// source takes one from this shared pool when it is opened and puts
+	// it back when it is exhausted, so sources opened later can reuse it;
+	// sources that are open at the same time each hold their own buffer.
+	bufPool := sync.Pool{
+		New: func() interface{} {
+			buf := make([]byte, 0, *maxLineBytes)
+			return &buf
+		},
+	}
 
-const (
-	timeNanoLayout  = "15:04:05.000000000"
-	timeMicroLayout = "15:04:05.000000"
-	timeMilliLayout = "15:04:05.000"
-	timeLayout      = "15:04:05"
-)
+	// Each source drives itself on its own goroutine, reading one line
+	// ahead of the merger into a single-slot channel. sem bounds how
+	// many sources may be mid-read at once to *concurrency, without
+	// making a source's progress depend on a worker being free to adopt
+	// it: that's what lets the merger seed its heap from every source
+	// even when len(objectNames) > concurrency.
+	sourceChans := make([]chan sourceMessage, len(objectNames))
+	sem := make(chan struct{}, *concurrency)
+	for i, name := range objectNames {
+		state := newSourceState(name, prefix, src, defaultLoc, sourceLocs, &bufPool)
+		sourceChans[i] = make(chan sourceMessage, 1)
+		go state.run(ctx, i, lf, *maxLineBytes, sem, sourceChans[i])
+	}
 
-func parseLineTime(line string, defaultValue time.Time) (time.Time, error) {
-	if match := timeNanoPattern.FindStringSubmatch(line); match != nil {
-		return time.Parse(timeNanoLayout, match[1])
+	bw := bufio.NewWriter(os.Stdout)
+	// fatal flushes whatever has already been written before exiting, so
+	// a mid-stream error (including ctx cancellation from Ctrl-C) doesn't
+	// lose output that's already been merged.
+	fatal := func(err error) {
+		bw.Flush()
+		log.Fatal(err)
 	}
-	if match := timeMicroPattern.FindStringSubmatch(line); match != nil {
-		return time.Parse(timeMicroLayout, match[1])
+
+	// pull takes source i's next already-in-flight line and, unless it's
+	// exhausted or erroring, pushes it onto the heap.
+	pull := func(h *lineHeap, i int) {
+		msg := <-sourceChans[i]
+		if msg.err != nil {
+			fatal(msg.err)
+		}
+		if !msg.eof {
+			heap.Push(h, lineHeapItem{line: msg.line, source: i})
+		}
 	}
-	if match := timeMilliPattern.FindStringSubmatch(line); match != nil {
-		return time.Parse(timeMilliLayout, match[1])
+
+	// k-way merge: seed the heap with each source's first line, then
+	// repeatedly pop the earliest and refill from that same source, so
+	// the heap holds at most one line per source at a time.
+	h := make(lineHeap, 0, len(objectNames))
+	for i := range objectNames {
+		pull(&h, i)
 	}
-	if match := timePattern.FindStringSubmatch(line); match != nil {
-		return time.Parse(timeLayout, match[1])
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		item := heap.Pop(&h).(lineHeapItem)
+		if _, err := bw.WriteString(formatter.format(item.line)); err != nil {
+			fatal(fmt.Errorf("failed to write string: %v", err))
+		}
+		pull(&h, item.source)
 	}
-	return defaultValue, nil
+	bw.Flush()
 }
 
 func shortName(name string) string {
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMergeManySourcesWithBoundedConcurrency reproduces the chunk0-4
+// review deadlock: many sources, each with more lines than a source's
+// response buffer, merged with concurrency well below the source count.
+// Before the fix, seeding the heap blocked forever because the bounded
+// worker pool could never get around to starting the later sources.
+func TestMergeManySourcesWithBoundedConcurrency(t *testing.T) {
+	const numSources = 100
+	const linesPerSource = 200
+
+	dir := t.TempDir()
+	for i := 0; i < numSources; i++ {
+		var b strings.Builder
+		for l := 0; l < linesPerSource; l++ {
+			ts := time.Date(2020, 6, 15, 0, 0, 0, 0, time.UTC).
+				Add(time.Duration(i) * time.Millisecond).
+				Add(time.Duration(l) * numSources * time.Millisecond)
+			fmt.Fprintf(&b, "%s line %d\n", ts.Format(time.RFC3339Nano), l)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("source-%03d.log", i))
+		if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error, 1)
+	var output []byte
+	go func() {
+		cmd := exec.Command("go", "run", ".", "--concurrency", "4", dir)
+		out, err := cmd.CombinedOutput()
+		output = out
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("combiner failed: %v\n%s", err, output)
+		}
+		lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+		if len(lines) != numSources*linesPerSource {
+			t.Fatalf("got %d lines, want %d", len(lines), numSources*linesPerSource)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("combiner did not finish within 30s; likely deadlocked")
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sourceFilter decides which sources (files/objects) get read at all, so
+// that excluded sources never pay the cost of a reader, a scanner buffer,
+// or a worker-pool slot.
+type sourceFilter struct {
+	includeGlob string
+	excludeGlob string
+	component   string // "pod=<name>", matched against the Kubernetes pod-log path convention
+}
+
+// podLogPattern matches Kubernetes pod-log paths of the form
+// ".../pods/<ns>_<pod>_<uid>/<container>/N.log".
+func podLogPattern(podName string) (*regexp.Regexp, error) {
+	return regexp.Compile(`/pods/[^_/]+_` + regexp.QuoteMeta(podName) + `_[^_/]+/`)
+}
+
+// matches reports whether the source with the given short name and full
+// object/file name should be read, given the filter's settings.
+func (f sourceFilter) matches(shortName, fullName string) (bool, error) {
+	if f.includeGlob != "" {
+		ok, err := path.Match(f.includeGlob, shortName)
+		if err != nil {
+			return false, fmt.Errorf("invalid --include-source pattern %q: %v", f.includeGlob, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	if f.excludeGlob != "" {
+		ok, err := path.Match(f.excludeGlob, shortName)
+		if err != nil {
+			return false, fmt.Errorf("invalid --exclude-source pattern %q: %v", f.excludeGlob, err)
+		}
+		if ok {
+			return false, nil
+		}
+	}
+	if f.component != "" {
+		key, value, ok := strings.Cut(f.component, "=")
+		if !ok {
+			return false, fmt.Errorf("invalid --component %q, expected key=value (e.g. pod=my-pod)", f.component)
+		}
+		switch key {
+		case "pod":
+			pattern, err := podLogPattern(value)
+			if err != nil {
+				return false, fmt.Errorf("invalid --component pod name %q: %v", value, err)
+			}
+			if !pattern.MatchString(fullName) {
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("unsupported --component key %q", key)
+		}
+	}
+	return true, nil
+}
+
+// lineFilter decides which lines of an included source make it into the
+// combined output. It is applied inside each worker as lines are scanned,
+// before they're ever sent for merging, so filtered-out lines cost
+// nothing downstream.
+type lineFilter struct {
+	grep  *regexp.Regexp
+	grepV *regexp.Regexp
+	since time.Time
+	until time.Time
+}
+
+// newLineFilter builds a lineFilter from the raw --grep/--grep-v/--since/
+// --until flag values, any of which may be empty to disable that check.
+func newLineFilter(grep, grepV, since, until string) (lineFilter, error) {
+	var f lineFilter
+	var err error
+	if grep != "" {
+		if f.grep, err = regexp.Compile(grep); err != nil {
+			return lineFilter{}, fmt.Errorf("invalid --grep pattern %q: %v", grep, err)
+		}
+	}
+	if grepV != "" {
+		if f.grepV, err = regexp.Compile(grepV); err != nil {
+			return lineFilter{}, fmt.Errorf("invalid --grep-v pattern %q: %v", grepV, err)
+		}
+	}
+	if since != "" {
+		if f.since, err = time.Parse(time.RFC3339, since); err != nil {
+			return lineFilter{}, fmt.Errorf("invalid --since time %q: %v", since, err)
+		}
+	}
+	if until != "" {
+		if f.until, err = time.Parse(time.RFC3339, until); err != nil {
+			return lineFilter{}, fmt.Errorf("invalid --until time %q: %v", until, err)
+		}
+	}
+	return f, nil
+}
+
+func (f lineFilter) allows(t time.Time, text string) bool {
+	if f.grep != nil && !f.grep.MatchString(text) {
+		return false
+	}
+	if f.grepV != nil && f.grepV.MatchString(text) {
+		return false
+	}
+	if !f.since.IsZero() && t.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && t.After(f.until) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// lineHeapItem is one source's current candidate line in the merge heap.
+type lineHeapItem struct {
+	line   logLine
+	source int
+}
+
+// lineHeap is a container/heap min-heap over lineHeapItems, ordered the
+// same way the old batch sort was: by parsed time, then source index,
+// then row number within the source.
+type lineHeap []lineHeapItem
+
+func (h lineHeap) Len() int { return len(h) }
+
+func (h lineHeap) Less(i, j int) bool {
+	a, b := h[i].line, h[j].line
+	if !a.time.Equal(b.time) {
+		return a.time.Before(b.time)
+	}
+	if a.sourceIndex != b.sourceIndex {
+		return a.sourceIndex < b.sourceIndex
+	}
+	return a.rowNumber < b.rowNumber
+}
+
+func (h lineHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *lineHeap) Push(x interface{}) {
+	*h = append(*h, x.(lineHeapItem))
+}
+
+func (h *lineHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
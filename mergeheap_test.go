@@ -0,0 +1,70 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+)
+
+func TestLineHeapOrdering(t *testing.T) {
+	t1 := time.Date(2020, 6, 15, 22, 10, 34, 0, time.UTC)
+	t2 := t1.Add(time.Second)
+
+	items := []lineHeapItem{
+		{line: logLine{time: t2, sourceIndex: 0, rowNumber: 0}, source: 0},
+		{line: logLine{time: t1, sourceIndex: 1, rowNumber: 0}, source: 1},
+		{line: logLine{time: t1, sourceIndex: 0, rowNumber: 1}, source: 0},
+		{line: logLine{time: t1, sourceIndex: 0, rowNumber: 0}, source: 0},
+	}
+
+	h := make(lineHeap, 0, len(items))
+	for _, item := range items {
+		heap.Push(&h, item)
+	}
+
+	var gotOrder []struct {
+		sourceIndex int
+		rowNumber   int
+	}
+	for h.Len() > 0 {
+		item := heap.Pop(&h).(lineHeapItem)
+		gotOrder = append(gotOrder, struct {
+			sourceIndex int
+			rowNumber   int
+		}{item.line.sourceIndex, item.line.rowNumber})
+	}
+
+	want := []struct {
+		sourceIndex int
+		rowNumber   int
+	}{
+		{0, 0}, // t1, source 0, row 0: earliest time, lowest source, lowest row
+		{0, 1}, // t1, source 0, row 1: same time and source, later row
+		{1, 0}, // t1, source 1, row 0: same time, higher source index
+		{0, 0}, // t2, source 0, row 0: latest time, popped last regardless of source/row
+	}
+	if len(gotOrder) != len(want) {
+		t.Fatalf("got %d items, want %d", len(gotOrder), len(want))
+	}
+	for i := range want {
+		if gotOrder[i] != want[i] {
+			t.Errorf("pop %d = %+v, want %+v", i, gotOrder[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+const lineTimeFormat = "2006-01-02T15:04:05.000000000"
+
+// lineFormatter renders one merged logLine as a complete output line,
+// including its trailing newline.
+type lineFormatter interface {
+	format(l logLine) string
+}
+
+// newLineFormatter picks a lineFormatter for the given --output value.
+// An empty format auto-selects color when stdout is a TTY and noColor
+// isn't set, and falls back to plain text otherwise.
+func newLineFormatter(format string, noColor bool) (lineFormatter, error) {
+	if format == "" {
+		if !noColor && isTerminal(os.Stdout) {
+			format = "color"
+		} else {
+			format = "text"
+		}
+	}
+	if noColor && format == "color" {
+		format = "text"
+	}
+	switch format {
+	case "text":
+		return textFormatter{}, nil
+	case "json":
+		return jsonFormatter{}, nil
+	case "color":
+		return colorFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("invalid --output %q, must be one of text, json, color", format)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return (stat.Mode() & os.ModeCharDevice) != 0
+}
+
+// textFormatter is the original plain "time [source] message" format.
+type textFormatter struct{}
+
+func (textFormatter) format(l logLine) string {
+	return fmt.Sprintf("%s %-62s %s\n", l.time.Format(lineTimeFormat), "["+l.shortName+"]", l.text)
+}
+
+// jsonFormatter emits one NDJSON object per line so the combined stream
+// can be piped into jq, Loki, or Elasticsearch.
+type jsonFormatter struct{}
+
+type jsonLine struct {
+	TS      string `json:"ts"`
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+func (jsonFormatter) format(l logLine) string {
+	b, err := json.Marshal(jsonLine{
+		TS:      l.time.Format(time.RFC3339Nano),
+		Source:  l.shortName,
+		Message: l.text,
+	})
+	if err != nil {
+		// jsonLine is all strings, so this can't realistically fail; if
+		// it somehow does, fall back to the plain text format for that
+		// line rather than dropping it.
+		return textFormatter{}.format(l)
+	}
+	return string(b) + "\n"
+}
+
+// colorFormatter behaves like textFormatter, but colorizes the source
+// prefix with a stable per-source ANSI 256 color (hashed from the short
+// name), similar to `kubectl logs --prefix`, so interleaved sources are
+// visually separable.
+type colorFormatter struct{}
+
+func (colorFormatter) format(l logLine) string {
+	prefix := fmt.Sprintf("%-62s", "["+l.shortName+"]")
+	return fmt.Sprintf("%s \x1b[38;5;%dm%s\x1b[0m %s\n", l.time.Format(lineTimeFormat), sourceColor(l.shortName), prefix, l.text)
+}
+
+// sourceColor maps a source's short name to a stable color in the ANSI
+// 256-color palette, avoiding the low-contrast ends of the range.
+func sourceColor(name string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return 17 + int(h.Sum32()%214)
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"cloud.google.com/go/storage"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// objectSource lists the log objects under a path and opens them for
+// reading. The three implementations below (local disk, GCS, S3) are
+// picked by newSource based on the scheme of the path the user gave.
+type objectSource interface {
+	// list returns the names of every matching log object, along with
+	// the prefix that should be trimmed from each name to produce its
+	// short display name.
+	list(ctx context.Context) (names []string, prefix string, err error)
+	// reader opens the named object for reading.
+	reader(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// isLogObject reports whether name looks like one of the log file types
+// this tool knows how to combine.
+func isLogObject(name string) bool {
+	return strings.HasSuffix(name, ".log") || strings.HasSuffix(name, "build-log.txt")
+}
+
+// newSource picks an objectSource for path, based on its scheme:
+// gs://, https://storage.googleapis.com/..., the GCS web console URL
+// format, s3://, or a plain local filesystem path.
+func newSource(ctx context.Context, path string) (objectSource, error) {
+	switch {
+	case strings.HasPrefix(path, "gs://"), isGCSConsoleURL(path), isGCSObjectURL(path):
+		bucket, prefix, err := parseGCSPath(path)
+		if err != nil {
+			return nil, err
+		}
+		client, err := newGCSClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create storage client: %v", err)
+		}
+		return &gcsSource{bucket: bucket, prefix: prefix, client: client}, nil
+	case strings.HasPrefix(path, "s3://"):
+		bucket, prefix, err := parseS3Path(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %v", err)
+		}
+		return &s3Source{bucket: bucket, prefix: prefix, client: s3.NewFromConfig(cfg)}, nil
+	default:
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get object absolute path from %v : %v", path, err)
+		}
+		return &localSource{root: absPath, prefix: absPath}, nil
+	}
+}
+
+// newGCSClient prefers explicit credentials from GOOGLE_APPLICATION_CREDENTIALS
+// (so private CI buckets work), and falls back to anonymous access so
+// public buckets like kubernetes-jenkins keep working with no setup.
+func newGCSClient(ctx context.Context) (*storage.Client, error) {
+	if credsFile := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); credsFile != "" {
+		return storage.NewClient(ctx, option.WithCredentialsFile(credsFile))
+	}
+	return storage.NewClient(ctx, option.WithoutAuthentication())
+}
+
+func isGCSObjectURL(path string) bool {
+	return strings.HasPrefix(path, "https://storage.googleapis.com/")
+}
+
+func isGCSConsoleURL(path string) bool {
+	return strings.HasPrefix(path, "https://console.cloud.google.com/storage/browser/")
+}
+
+// parseGCSPath extracts a bucket name and object prefix from a
+// gs://bucket/prefix URI, a https://storage.googleapis.com/bucket/prefix
+// URL, or a https://console.cloud.google.com/storage/browser/bucket/prefix
+// web console URL.
+func parseGCSPath(path string) (bucket, prefix string, err error) {
+	var rest string
+	switch {
+	case strings.HasPrefix(path, "gs://"):
+		rest = strings.TrimPrefix(path, "gs://")
+	case isGCSObjectURL(path):
+		rest = strings.TrimPrefix(path, "https://storage.googleapis.com/")
+	case isGCSConsoleURL(path):
+		rest = strings.TrimPrefix(path, "https://console.cloud.google.com/storage/browser/")
+	default:
+		return "", "", fmt.Errorf("unrecognized GCS path: %v", path)
+	}
+
+	u, err := url.Parse("gs://" + rest)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to parse GCS path %v: %v", path, err)
+	}
+	bucket = u.Host
+	prefix = strings.TrimPrefix(u.Path, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("unable to determine bucket from %v", path)
+	}
+	return bucket, prefix, nil
+}
+
+// parseS3Path extracts a bucket name and object prefix from an
+// s3://bucket/prefix URI.
+func parseS3Path(path string) (bucket, prefix string, err error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to parse S3 path %v: %v", path, err)
+	}
+	bucket = u.Host
+	prefix = strings.TrimPrefix(u.Path, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("unable to determine bucket from %v", path)
+	}
+	return bucket, prefix, nil
+}
+
+// localSource reads log files from the local filesystem.
+type localSource struct {
+	root   string
+	prefix string
+}
+
+func (s *localSource) list(_ context.Context) ([]string, string, error) {
+	var names []string
+	err := filepath.Walk(s.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isLogObject(path) {
+			names = append(names, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get object names from path %v : %v", s.root, err)
+	}
+	return names, s.prefix, nil
+}
+
+func (s *localSource) reader(_ context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+// gcsSource reads log files out of a Google Cloud Storage bucket.
+type gcsSource struct {
+	bucket string
+	prefix string
+	client *storage.Client
+}
+
+func (s *gcsSource) list(ctx context.Context) ([]string, string, error) {
+	bucket := s.client.Bucket(s.bucket)
+	q := &storage.Query{Prefix: s.prefix}
+	if err := q.SetAttrSelection([]string{"Name"}); err != nil {
+		return nil, "", fmt.Errorf("failed to set attr selection: %v", err)
+	}
+
+	var names []string
+	objects := bucket.Objects(ctx, q)
+	for {
+		objAttrs, err := objects.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("iterator error: %v", err)
+		}
+		if isLogObject(objAttrs.Name) {
+			names = append(names, objAttrs.Name)
+		}
+	}
+	return names, s.prefix, nil
+}
+
+func (s *gcsSource) reader(ctx context.Context, name string) (io.ReadCloser, error) {
+	return s.client.Bucket(s.bucket).Object(name).NewReader(ctx)
+}
+
+// s3Source reads log files out of an Amazon S3 bucket.
+type s3Source struct {
+	bucket string
+	prefix string
+	client *s3.Client
+}
+
+func (s *s3Source) list(ctx context.Context) ([]string, string, error) {
+	var names []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list objects in s3://%v/%v: %v", s.bucket, s.prefix, err)
+		}
+		for _, obj := range page.Contents {
+			if obj.Key != nil && isLogObject(*obj.Key) {
+				names = append(names, *obj.Key)
+			}
+		}
+	}
+	return names, s.prefix, nil
+}
+
+func (s *s3Source) reader(ctx context.Context, name string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+func TestParseGCSPath(t *testing.T) {
+	cases := []struct {
+		name       string
+		path       string
+		wantBucket string
+		wantPrefix string
+		wantErr    bool
+	}{
+		{
+			name:       "gs uri with prefix",
+			path:       "gs://my-bucket/logs/e2e",
+			wantBucket: "my-bucket",
+			wantPrefix: "logs/e2e",
+		},
+		{
+			name:       "gs uri with no prefix",
+			path:       "gs://my-bucket",
+			wantBucket: "my-bucket",
+			wantPrefix: "",
+		},
+		{
+			name:       "storage.googleapis.com url",
+			path:       "https://storage.googleapis.com/my-bucket/logs/e2e",
+			wantBucket: "my-bucket",
+			wantPrefix: "logs/e2e",
+		},
+		{
+			name:       "console.cloud.google.com url",
+			path:       "https://console.cloud.google.com/storage/browser/my-bucket/logs/e2e",
+			wantBucket: "my-bucket",
+			wantPrefix: "logs/e2e",
+		},
+		{
+			name:    "unrecognized path",
+			path:    "https://example.com/my-bucket/logs",
+			wantErr: true,
+		},
+		{
+			name:    "gs uri with no bucket",
+			path:    "gs://",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bucket, prefix, err := parseGCSPath(c.path)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseGCSPath(%q) = nil error, want error", c.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGCSPath(%q) returned unexpected error: %v", c.path, err)
+			}
+			if bucket != c.wantBucket || prefix != c.wantPrefix {
+				t.Errorf("parseGCSPath(%q) = (%q, %q), want (%q, %q)", c.path, bucket, prefix, c.wantBucket, c.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestParseS3Path(t *testing.T) {
+	cases := []struct {
+		name       string
+		path       string
+		wantBucket string
+		wantPrefix string
+		wantErr    bool
+	}{
+		{
+			name:       "s3 uri with prefix",
+			path:       "s3://my-bucket/logs/e2e",
+			wantBucket: "my-bucket",
+			wantPrefix: "logs/e2e",
+		},
+		{
+			name:       "s3 uri with no prefix",
+			path:       "s3://my-bucket",
+			wantBucket: "my-bucket",
+			wantPrefix: "",
+		},
+		{
+			name:    "s3 uri with no bucket",
+			path:    "s3://",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bucket, prefix, err := parseS3Path(c.path)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseS3Path(%q) = nil error, want error", c.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseS3Path(%q) returned unexpected error: %v", c.path, err)
+			}
+			if bucket != c.wantBucket || prefix != c.wantPrefix {
+				t.Errorf("parseS3Path(%q) = (%q, %q), want (%q, %q)", c.path, bucket, prefix, c.wantBucket, c.wantPrefix)
+			}
+		})
+	}
+}
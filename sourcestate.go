@@ -0,0 +1,127 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sourceState holds one source's open reader and scan position across
+// multiple advance calls. Sources are opened lazily, on their first
+// advance, and closed as soon as they're exhausted.
+//
+// advance is never called concurrently for the same sourceState: only
+// the source's own run goroutine calls it, so no locking is needed here.
+type sourceState struct {
+	name      string
+	src       objectSource
+	shortName string
+	loc       *time.Location
+	bufPool   *sync.Pool
+
+	reader    io.ReadCloser
+	scanner   *bufio.Scanner
+	bufPtr    *[]byte
+	opened    bool
+	lineTime  time.Time
+	rowNumber int
+}
+
+func newSourceState(name, prefix string, src objectSource, defaultLoc *time.Location, sourceLocs map[string]*time.Location, bufPool *sync.Pool) *sourceState {
+	short := shortName(strings.TrimPrefix(name, prefix))
+	loc := defaultLoc
+	if override, ok := sourceLocs[short]; ok {
+		loc = override
+	}
+	return &sourceState{name: name, src: src, shortName: short, loc: loc, bufPool: bufPool}
+}
+
+// advance returns this source's next line that passes lf, or a terminal
+// eof/err result once the source is exhausted. The underlying reader is
+// opened on the first call and closed (with its scanner buffer returned
+// to bufPool) as soon as advance reaches EOF or an error.
+func (s *sourceState) advance(ctx context.Context, sourceIndex int, lf lineFilter, maxLineBytes int) sourceMessage {
+	if !s.opened {
+		reader, err := s.src.reader(ctx, s.name)
+		if err != nil {
+			return sourceMessage{err: fmt.Errorf("failed to create new reader for %v: %v", s.name, err)}
+		}
+		s.reader = reader
+		s.bufPtr = s.bufPool.Get().(*[]byte)
+		s.scanner = bufio.NewScanner(reader)
+		s.scanner.Buffer((*s.bufPtr)[:0], maxLineBytes)
+		s.scanner.Split(bufio.ScanLines)
+		s.opened = true
+	}
+
+	for s.scanner.Scan() {
+		s.rowNumber++
+		line := s.scanner.Text()
+		s.lineTime = parseLineTime(line, s.lineTime, s.loc)
+		if !lf.allows(s.lineTime, line) {
+			continue
+		}
+		return sourceMessage{line: logLine{
+			time:        s.lineTime,
+			sourceIndex: sourceIndex,
+			rowNumber:   s.rowNumber,
+			shortName:   s.shortName,
+			text:        line,
+		}}
+	}
+
+	err := s.scanner.Err()
+	s.close()
+	if err != nil {
+		return sourceMessage{err: err}
+	}
+	return sourceMessage{eof: true}
+}
+
+func (s *sourceState) close() {
+	s.reader.Close()
+	s.bufPool.Put(s.bufPtr)
+}
+
+// run drives this source to completion, sending one sourceMessage per
+// advance call to out until it sees eof or an error. Each advance is
+// gated by sem, which bounds how many sources across the whole merge
+// may be mid-read at once; out is buffered to depth 1, so a source
+// can't race ahead of the merger by more than two lines: one waiting in
+// out and one already read and blocked on being sent.
+//
+// Because every source drives itself independently (rather than
+// waiting its turn behind other sources in a shared worker pool), the
+// merger can seed its heap by reading each source's first line in any
+// order without one source's backlog starving another's progress.
+func (s *sourceState) run(ctx context.Context, sourceIndex int, lf lineFilter, maxLineBytes int, sem chan struct{}, out chan<- sourceMessage) {
+	for {
+		sem <- struct{}{}
+		msg := s.advance(ctx, sourceIndex, lf, maxLineBytes)
+		<-sem
+		out <- msg
+		if msg.eof || msg.err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// lineTimeParser extracts a timestamp from a single log line. It returns
+// ok=false when the line does not match the format it handles, so that
+// parseLineTime can fall through to the next registered parser.
+type lineTimeParser interface {
+	parse(line string, prev time.Time, loc *time.Location) (t time.Time, ok bool)
+}
+
+// lineTimeParsers are tried in order for every line. More specific formats
+// (full date+time, JSON) are tried before the bare time-of-day formats so
+// that a JSON log body containing a coincidental "22:10:34" substring is
+// still parsed using its "ts" field.
+var lineTimeParsers = []lineTimeParser{
+	jsonTimeParser{},
+	rfc3339TimeParser{},
+	klogTimeParser{},
+	bareTimeParser{},
+}
+
+// parseLineTime extracts a timestamp from line, trying each registered
+// parser in turn. prev is the most recently parsed timestamp for this
+// source, used both as a fallback when a line carries no timestamp of its
+// own and to resolve dates for formats (klog, bare HH:MM:SS) that don't
+// carry a full date. loc is used when a format has no explicit zone. If
+// no parser recognizes the line, prev is returned unchanged.
+func parseLineTime(line string, prev time.Time, loc *time.Location) time.Time {
+	for _, p := range lineTimeParsers {
+		if t, ok := p.parse(line, prev, loc); ok {
+			return t
+		}
+	}
+	return prev
+}
+
+// rfc3339TimeParser matches full RFC3339/ISO8601 timestamps such as
+// "2020-06-15T22:10:34.002031939Z" or "2020-06-15T22:10:34-07:00".
+type rfc3339TimeParser struct{}
+
+var rfc3339Pattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+
+func (rfc3339TimeParser) parse(line string, _ time.Time, _ *time.Location) (time.Time, bool) {
+	match := rfc3339Pattern.FindString(line)
+	if match == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, match)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// klogTimeParser matches klog-style headers, e.g. "I0615 22:10:34.002031".
+// klog doesn't include a year, so it is taken from prev (the previous
+// timestamp seen for this source), falling back to the current year.
+type klogTimeParser struct{}
+
+var klogPattern = regexp.MustCompile(`[IWEF](\d{2})(\d{2}) (\d{2}):(\d{2}):(\d{2})(\.\d+)?`)
+
+func (klogTimeParser) parse(line string, prev time.Time, loc *time.Location) (time.Time, bool) {
+	match := klogPattern.FindStringSubmatch(line)
+	if match == nil {
+		return time.Time{}, false
+	}
+	year := time.Now().Year()
+	if !prev.IsZero() {
+		year = prev.Year()
+	}
+	timestamp := fmt.Sprintf("%04d %s%s %s:%s:%s%s", year, match[1], match[2], match[3], match[4], match[5], match[6])
+	t, err := time.ParseInLocation("2006 0102 15:04:05.999999999", timestamp, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// klog headers roll over a calendar year without warning; if this
+	// timestamp looks like it's a long way before prev, assume it's from
+	// the following year rather than treating it as out of order.
+	if !prev.IsZero() && t.Before(prev.Add(-350*24*time.Hour)) {
+		t = t.AddDate(1, 0, 0)
+	}
+	return t, true
+}
+
+// jsonTimeParser matches structured JSON log lines with a "ts" or "time"
+// field, as emitted by klog's --logging-format=json and similar loggers.
+// The field may be an RFC3339 string or a float number of seconds since
+// the epoch.
+type jsonTimeParser struct{}
+
+func (jsonTimeParser) parse(line string, _ time.Time, loc *time.Location) (time.Time, bool) {
+	trimmed := line
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return time.Time{}, false
+	}
+	var fields struct {
+		TS   json.RawMessage `json:"ts"`
+		Time string          `json:"time"`
+	}
+	if err := json.Unmarshal([]byte(trimmed), &fields); err != nil {
+		return time.Time{}, false
+	}
+	if t, ok := parseJSONTimestamp(fields.TS, loc); ok {
+		return t, true
+	}
+	if fields.Time != "" {
+		if t, err := time.Parse(time.RFC3339Nano, fields.Time); err == nil {
+			return t.In(loc), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseJSONTimestamp interprets a raw "ts" field as either a quoted
+// RFC3339 string or a bare/quoted number of seconds since the epoch, since
+// different structured loggers emit it both ways.
+func parseJSONTimestamp(raw json.RawMessage, loc *time.Location) (time.Time, bool) {
+	if len(raw) == 0 {
+		return time.Time{}, false
+	}
+	var s string
+	var seconds float64
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t.In(loc), true
+		}
+		if seconds, err = strconv.ParseFloat(s, 64); err != nil {
+			return time.Time{}, false
+		}
+	} else if err := json.Unmarshal(raw, &seconds); err != nil {
+		return time.Time{}, false
+	}
+	whole := int64(seconds)
+	nanos := int64((seconds - float64(whole)) * float64(time.Second))
+	return time.Unix(whole, nanos).In(loc), true
+}
+
+// bareTimeParser matches a plain HH:MM:SS[.frac] time of day, with no
+// date, as emitted by many e2e test binaries. The date is taken from prev
+// and rolled forward a day when the time of day is more than 12 hours
+// earlier than prev, handling runs that span midnight.
+type bareTimeParser struct{}
+
+var (
+	bareTimeNanoPattern  = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}\.\d{9})`)
+	bareTimeMicroPattern = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}\.\d{6})`)
+	bareTimeMilliPattern = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}\.\d{3})`)
+	bareTimePattern      = regexp.MustCompile(`(\d{2}:\d{2}:\d{2})`)
+)
+
+const (
+	bareTimeNanoLayout  = "15:04:05.000000000"
+	bareTimeMicroLayout = "15:04:05.000000"
+	bareTimeMilliLayout = "15:04:05.000"
+	bareTimeLayout      = "15:04:05"
+)
+
+func (bareTimeParser) parse(line string, prev time.Time, loc *time.Location) (time.Time, bool) {
+	var match string
+	var layout string
+	switch {
+	case bareTimeNanoPattern.MatchString(line):
+		match = bareTimeNanoPattern.FindString(line)
+		layout = bareTimeNanoLayout
+	case bareTimeMicroPattern.MatchString(line):
+		match = bareTimeMicroPattern.FindString(line)
+		layout = bareTimeMicroLayout
+	case bareTimeMilliPattern.MatchString(line):
+		match = bareTimeMilliPattern.FindString(line)
+		layout = bareTimeMilliLayout
+	case bareTimePattern.MatchString(line):
+		match = bareTimePattern.FindString(line)
+		layout = bareTimeLayout
+	default:
+		return time.Time{}, false
+	}
+
+	timeOfDay, err := time.Parse(layout, match)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	year, month, day := time.Now().Date()
+	if !prev.IsZero() {
+		year, month, day = prev.Date()
+	}
+	t := time.Date(year, month, day, timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(), timeOfDay.Nanosecond(), loc)
+	if !prev.IsZero() && t.Before(prev.Add(-12*time.Hour)) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
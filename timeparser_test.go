@@ -0,0 +1,80 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLineTime(t *testing.T) {
+	loc := time.UTC
+	cases := []struct {
+		name string
+		line string
+		prev time.Time
+		want time.Time
+	}{
+		{
+			name: "rfc3339 line",
+			line: `I am a log line at 2020-06-15T22:10:34.002031939Z with stuff around it`,
+			want: time.Date(2020, 6, 15, 22, 10, 34, 2031939, time.UTC),
+		},
+		{
+			name: "json line with string ts",
+			line: `{"ts":"2020-06-15T22:10:34.002Z","msg":"hello"}`,
+			want: time.Date(2020, 6, 15, 22, 10, 34, 2000000, time.UTC),
+		},
+		{
+			name: "json line with numeric ts",
+			line: `{"ts":1592259034.25,"msg":"hello"}`,
+			want: time.Unix(1592259034, 250000000).In(time.UTC),
+		},
+		{
+			name: "json line with time field",
+			line: `{"time":"2020-06-15T22:10:34.002Z","msg":"hello"}`,
+			want: time.Date(2020, 6, 15, 22, 10, 34, 2000000, time.UTC),
+		},
+		{
+			name: "klog line uses prev year",
+			line: `I0615 22:10:34.002031 1 main.go:1] hello`,
+			prev: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2019, 6, 15, 22, 10, 34, 2031000, time.UTC),
+		},
+		{
+			name: "bare time rolls over to next day",
+			line: `00:01:00.000 hello`,
+			prev: time.Date(2020, 6, 15, 23, 59, 0, 0, time.UTC),
+			want: time.Date(2020, 6, 16, 0, 1, 0, 0, time.UTC),
+		},
+		{
+			name: "no timestamp falls back to prev",
+			line: `hello, no timestamp here`,
+			prev: time.Date(2020, 6, 15, 22, 10, 34, 0, time.UTC),
+			want: time.Date(2020, 6, 15, 22, 10, 34, 0, time.UTC),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := parseLineTime(c.line, c.prev, loc)
+			if !got.Equal(c.want) {
+				t.Errorf("parseLineTime(%q) = %v, want %v", c.line, got, c.want)
+			}
+		})
+	}
+}
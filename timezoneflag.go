@@ -0,0 +1,56 @@
+/*
+Copyright 2020 Brian Pursley
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sourceTimezoneFlag collects repeated -source-timezone name=zone values
+// into a flag.Value, so it can be passed multiple times on the command
+// line (one per source that needs a non-default zone).
+type sourceTimezoneFlag []string
+
+func (f *sourceTimezoneFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *sourceTimezoneFlag) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected name=zone, got %q", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// locations resolves each collected name=zone pair into a *time.Location,
+// keyed by source short name.
+func (f sourceTimezoneFlag) locations() (map[string]*time.Location, error) {
+	locs := make(map[string]*time.Location, len(f))
+	for _, entry := range f {
+		parts := strings.SplitN(entry, "=", 2)
+		name, zone := parts[0], parts[1]
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", entry, err)
+		}
+		locs[name] = loc
+	}
+	return locs, nil
+}